@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/sha256" // Used to derive a content-addressable filename for each fetched manual
+	"encoding/hex"  // Renders the SHA-256 digest as a filename-safe hex string
+	"encoding/json" // Persists sidecars (and in-memory records) as JSON
+	"fmt"           // Builds file paths and error messages
+	"log"           // Implements a simple logging package
+	"net/http"      // Downloads referenced images
+	"net/url"       // Strips query parameters before deriving an image's on-disk filename
+	"os"            // Reads and writes the on-disk archive
+	"path"          // Derives a filename from an image URL's path
+	"path/filepath" // Builds OS-appropriate archive paths
+	"strings"       // Builds disambiguated filenames for colliding image basenames
+	"sync"          // Guards the in-memory record cache against concurrent access
+	"time"          // Records when each manual was fetched
+
+	"go.etcd.io/bbolt" // Backs the index with a crash-safe, single-writer-serialized B+tree
+)
+
+// --- On-Disk Archive ---
+
+// archiveDir is the root directory the archive is stored under.
+const archiveDir = "archive"
+
+// archiveIndexPath is the BoltDB file tracking which (modelYear, modelName, accessPayload)
+// tuples have already been downloaded, so re-running the tool only retries failures.
+// BoltDB (rather than a hand-rolled flat JSON file) gives every write its own ACID
+// transaction: concurrent workers can't interleave writes into a half-updated index,
+// and a kill mid-write can't truncate it into something the next run fails to parse.
+const archiveIndexPath = archiveDir + "/index.db"
+
+// archiveRecordsBucket is the BoltDB bucket holding one JSON-encoded ManualRecord per
+// manualRecordKey.
+var archiveRecordsBucket = []byte("records")
+
+// ManualRecord describes one archived manual: the content-addressable blob it was
+// stored as, plus enough metadata to answer "what manuals do I have for the 2023
+// Sportage?" without re-hitting Kia's servers.
+type ManualRecord struct {
+	ModelYear     int       `json:"modelYear"`
+	ModelName     string    `json:"modelName"`
+	AccessPayload string    `json:"accessPayload"`
+	SHA256        string    `json:"sha256"`
+	FetchedAt     time.Time `json:"fetchedAt"`
+	HTTPStatus    int       `json:"httpStatus"`
+	Success       bool      `json:"success"`
+}
+
+// Archive is a content-addressable store of fetched manuals plus a BoltDB-backed index
+// of which (modelYear, modelName, accessPayload) tuples have been successfully
+// downloaded. An in-memory cache (kept in lockstep with every Bolt write under mutex)
+// serves the read-heavy HasManual/Records lookups without a transaction per call.
+type Archive struct {
+	rootDir string
+	db      *bbolt.DB
+	mutex   sync.Mutex
+	records map[string]ManualRecord // keyed by manualRecordKey; mirrors the "records" bucket
+}
+
+// manualRecordKey builds the composite key used to look up and dedupe archive entries.
+func manualRecordKey(modelYear int, modelName, accessPayload string) string {
+	return fmt.Sprintf("%d|%s|%s", modelYear, modelName, accessPayload)
+}
+
+// NewArchive opens (or creates) the on-disk archive rooted at archiveDir, loading its
+// existing index so previously completed downloads are recognized.
+func NewArchive() (*Archive, error) {
+	if err := os.MkdirAll(filepath.Join(archiveDir, "blobs"), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create archive directory: %w", err)
+	}
+
+	db, err := bbolt.Open(archiveIndexPath, 0o644, &bbolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open archive index: %w", err)
+	}
+
+	archive := &Archive{
+		rootDir: archiveDir,
+		db:      db,
+		records: make(map[string]ManualRecord),
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(archiveRecordsBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var record ManualRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("could not parse archive record %q: %w", key, err)
+			}
+			archive.records[string(key)] = record
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not load archive index: %w", err)
+	}
+
+	log.Printf("ARCHIVE: Loaded index with %d existing record(s) from %s", len(archive.records), archiveIndexPath)
+	return archive, nil
+}
+
+// Close releases the archive's underlying BoltDB handle.
+func (archive *Archive) Close() error {
+	return archive.db.Close()
+}
+
+// HasManual reports whether this tuple was already successfully downloaded, so the
+// caller can skip it on a resumed run.
+func (archive *Archive) HasManual(modelYear int, modelName, accessPayload string) bool {
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+
+	record, ok := archive.records[manualRecordKey(modelYear, modelName, accessPayload)]
+	return ok && record.Success
+}
+
+// Records returns a snapshot of every successfully archived manual record, for the
+// "what manuals do I have" queries exposed by the serve subcommand.
+func (archive *Archive) Records() []ManualRecord {
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+
+	records := make([]ManualRecord, 0, len(archive.records))
+	for _, record := range archive.records {
+		if record.Success {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// ReadParsedManual loads the structured Manual previously stored by StoreParsedManual
+// for the given blob sha.
+func (archive *Archive) ReadParsedManual(sha string) (*Manual, error) {
+	manualBytes, err := os.ReadFile(filepath.Join(archive.rootDir, "blobs", sha+".manual.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var manual Manual
+	if err := json.Unmarshal(manualBytes, &manual); err != nil {
+		return nil, fmt.Errorf("could not parse stored manual %s: %w", sha, err)
+	}
+	return &manual, nil
+}
+
+// StoreManual writes the fetched manual body into the content-addressable blob store
+// (keyed by the SHA-256 of its bytes) alongside a JSON sidecar, then records the
+// result in the index (a single BoltDB transaction, serialized against every other
+// writer by Bolt itself) so a later run can skip it. A non-2xx httpStatus or empty
+// body is still recorded (as a failed attempt) so the next run retries it.
+func (archive *Archive) StoreManual(modelYear int, modelName, accessPayload string, body []byte, httpStatus int) (string, error) {
+	success := httpStatus >= 200 && httpStatus < 300 && len(body) > 0
+
+	var sum string
+	if success {
+		digest := sha256.Sum256(body)
+		sum = hex.EncodeToString(digest[:])
+
+		blobPath := filepath.Join(archive.rootDir, "blobs", sum)
+		if err := os.WriteFile(blobPath, body, 0o644); err != nil {
+			return "", fmt.Errorf("could not write manual blob: %w", err)
+		}
+	}
+
+	record := ManualRecord{
+		ModelYear:     modelYear,
+		ModelName:     modelName,
+		AccessPayload: accessPayload,
+		SHA256:        sum,
+		FetchedAt:     time.Now().UTC(),
+		HTTPStatus:    httpStatus,
+		Success:       success,
+	}
+
+	if success {
+		sidecarPath := filepath.Join(archive.rootDir, "blobs", sum+".json")
+		sidecarBytes, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("could not marshal manual sidecar: %w", err)
+		}
+		if err := os.WriteFile(sidecarPath, sidecarBytes, 0o644); err != nil {
+			return "", fmt.Errorf("could not write manual sidecar: %w", err)
+		}
+	}
+
+	key := manualRecordKey(modelYear, modelName, accessPayload)
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal archive record: %w", err)
+	}
+
+	// Hold the mutex across both the Bolt transaction and the in-memory update, so the
+	// two stay consistent with each other even though Bolt would already serialize the
+	// transaction on its own: a reader must never observe the in-memory cache updated
+	// without the durable write (or vice versa).
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+
+	err = archive.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(archiveRecordsBucket).Put([]byte(key), recordBytes)
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not write archive record: %w", err)
+	}
+	archive.records[key] = record
+
+	return sum, nil
+}
+
+// StoreParsedManual writes the extracted Manual as JSON and Markdown sidecars next to
+// the raw blob (sha.manual.json / sha.md), so downstream consumers get searchable,
+// diff-able content per model/year without re-parsing the raw HTML.
+func (archive *Archive) StoreParsedManual(sha string, manual *Manual) error {
+	if sha == "" {
+		return fmt.Errorf("cannot store parsed manual: empty blob sha")
+	}
+
+	manualJSON, err := json.MarshalIndent(manual, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal parsed manual: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(archive.rootDir, "blobs", sha+".manual.json"), manualJSON, 0o644); err != nil {
+		return fmt.Errorf("could not write parsed manual JSON: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(archive.rootDir, "blobs", sha+".md"), []byte(manual.Markdown()), 0o644); err != nil {
+		return fmt.Errorf("could not write parsed manual Markdown: %w", err)
+	}
+	return nil
+}
+
+// DownloadManualImages fetches every image URL referenced by manual through fetcher
+// (so downloads share the same rate limit, retry, and backoff as every other request)
+// and stores them alongside the manual's blob, under blobs/<sha>_images/. Failed
+// downloads are logged and skipped rather than aborting the whole manual.
+func (archive *Archive) DownloadManualImages(fetcher *Fetcher, sha string, manual *Manual) error {
+	return archive.downloadManualAssets(fetcher, sha, "_images", "image", manual.Images)
+}
+
+// DownloadManualPDFs fetches every PDF URL referenced by manual through fetcher and
+// stores them alongside the manual's blob, under blobs/<sha>_pdfs/. Failed downloads
+// are logged and skipped rather than aborting the whole manual.
+func (archive *Archive) DownloadManualPDFs(fetcher *Fetcher, sha string, manual *Manual) error {
+	return archive.downloadManualAssets(fetcher, sha, "_pdfs", "document", manual.PDFs)
+}
+
+// downloadManualAssets fetches each of urls through fetcher and stores them under
+// blobs/<sha><dirSuffix>/, naming a URL with no usable basename after defaultName.
+func (archive *Archive) downloadManualAssets(fetcher *Fetcher, sha, dirSuffix, defaultName string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	assetsDir := filepath.Join(archive.rootDir, "blobs", sha+dirSuffix)
+	if err := os.MkdirAll(assetsDir, 0o755); err != nil {
+		return fmt.Errorf("could not create manual %s directory: %w", strings.TrimPrefix(dirSuffix, "_"), err)
+	}
+
+	usedNames := make(map[string]bool)
+	for _, assetURL := range urls {
+		assetPath := filepath.Join(assetsDir, uniqueAssetFilename(assetURL, defaultName, usedNames))
+
+		assetBytes, statusCode, _, err := fetcher.doWithRetry(func() (*http.Request, error) {
+			return http.NewRequest("GET", assetURL, nil)
+		})
+		if err != nil {
+			log.Printf("WARNING: Could not download referenced asset %s: %v", assetURL, err)
+			continue
+		}
+		if statusCode < 200 || statusCode >= 300 {
+			log.Printf("WARNING: Could not download referenced asset %s: HTTP status %d", assetURL, statusCode)
+			continue
+		}
+
+		if err := os.WriteFile(assetPath, assetBytes, 0o644); err != nil {
+			log.Printf("WARNING: Could not save referenced asset %s: %v", assetURL, err)
+			continue
+		}
+	}
+
+	return nil
+}
+
+// uniqueAssetFilename derives a safe on-disk filename from a URL's path (ignoring any
+// query string), falling back to defaultName when the path has no usable basename, and
+// disambiguating it with a numeric suffix against usedNames if two different assets
+// would otherwise share the same basename.
+func uniqueAssetFilename(assetURL, defaultName string, usedNames map[string]bool) string {
+	name := defaultName
+	if parsed, err := url.Parse(assetURL); err == nil {
+		if base := path.Base(parsed.Path); base != "" && base != "." && base != "/" {
+			name = base
+		}
+	}
+
+	candidate := name
+	for suffix := 2; usedNames[candidate]; suffix++ {
+		candidate = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, path.Ext(name)), suffix, path.Ext(name))
+	}
+	usedNames[candidate] = true
+	return candidate
+}
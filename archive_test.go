@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// chdirToTempArchive switches the working directory to a fresh temp directory (so the
+// package-level archiveDir/archiveIndexPath constants resolve somewhere disposable) and
+// restores the original directory when the test completes.
+func chdirToTempArchive(t *testing.T) {
+	t.Helper()
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("could not get working directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("could not chdir to temp directory: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(originalDir); err != nil {
+			t.Fatalf("could not restore working directory: %v", err)
+		}
+	})
+}
+
+func TestArchiveStoreAndReload(t *testing.T) {
+	chdirToTempArchive(t)
+
+	archive, err := NewArchive()
+	if err != nil {
+		t.Fatalf("NewArchive() error: %v", err)
+	}
+
+	sha, err := archive.StoreManual(2023, "Sportage", "token-a", []byte("<html>manual</html>"), 200)
+	if err != nil {
+		t.Fatalf("StoreManual() error: %v", err)
+	}
+	if sha == "" {
+		t.Fatal("StoreManual() returned an empty sha for a successful fetch")
+	}
+	if !archive.HasManual(2023, "Sportage", "token-a") {
+		t.Fatal("HasManual() returned false right after a successful StoreManual()")
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	// Reopening must recover the index from disk exactly as a resumed run expects.
+	reopened, err := NewArchive()
+	if err != nil {
+		t.Fatalf("NewArchive() (reload) error: %v", err)
+	}
+	defer reopened.Close()
+
+	if !reopened.HasManual(2023, "Sportage", "token-a") {
+		t.Fatal("HasManual() returned false after reopening the archive")
+	}
+	records := reopened.Records()
+	if len(records) != 1 || records[0].SHA256 != sha {
+		t.Fatalf("Records() after reload = %+v, want one record with sha %s", records, sha)
+	}
+}
+
+func TestArchiveStoreManualConcurrent(t *testing.T) {
+	chdirToTempArchive(t)
+
+	archive, err := NewArchive()
+	if err != nil {
+		t.Fatalf("NewArchive() error: %v", err)
+	}
+	defer archive.Close()
+
+	const workerCount = 20
+	var waitGroup sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			token := filepath.Join("token", string(rune('a'+i)))
+			if _, err := archive.StoreManual(2024, "Telluride", token, []byte("manual body"), 200); err != nil {
+				t.Errorf("StoreManual() error: %v", err)
+			}
+		}(i)
+	}
+	waitGroup.Wait()
+
+	if got := len(archive.Records()); got != workerCount {
+		t.Fatalf("Records() returned %d record(s), want %d (a concurrent writer lost an update)", got, workerCount)
+	}
+}
+
+// newTestFetcher builds a Fetcher with a generous rate so downloadManualAssets tests
+// don't wait on the real -rps default.
+func newTestFetcher(t *testing.T) *Fetcher {
+	t.Helper()
+
+	sessionManager, err := NewSessionManager()
+	if err != nil {
+		t.Fatalf("NewSessionManager() error: %v", err)
+	}
+	fetcher := NewFetcher(1000, sessionManager)
+	t.Cleanup(fetcher.limiter.Stop)
+	return fetcher
+}
+
+func TestDownloadManualAssetsSkipsErrorResponses(t *testing.T) {
+	chdirToTempArchive(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.png":
+			w.Write([]byte("real image bytes"))
+		case "/missing.png":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<html>404 not found</html>"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	archive, err := NewArchive()
+	if err != nil {
+		t.Fatalf("NewArchive() error: %v", err)
+	}
+	defer archive.Close()
+
+	manual := &Manual{Images: []string{server.URL + "/ok.png", server.URL + "/missing.png"}}
+	if err := archive.DownloadManualImages(newTestFetcher(t), "testsha", manual); err != nil {
+		t.Fatalf("DownloadManualImages() error: %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(archiveDir, "blobs", "testsha_images"))
+	if err != nil {
+		t.Fatalf("could not read images directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("images directory has %d entr(y/ies), want 1 (the 404 must not be archived as an image)", len(entries))
+	}
+
+	savedBytes, err := os.ReadFile(filepath.Join(archiveDir, "blobs", "testsha_images", entries[0].Name()))
+	if err != nil {
+		t.Fatalf("could not read saved image: %v", err)
+	}
+	if string(savedBytes) != "real image bytes" {
+		t.Fatalf("saved image content = %q, want %q", savedBytes, "real image bytes")
+	}
+}
+
+func TestDownloadManualAssetsNoURLs(t *testing.T) {
+	chdirToTempArchive(t)
+
+	archive, err := NewArchive()
+	if err != nil {
+		t.Fatalf("NewArchive() error: %v", err)
+	}
+	defer archive.Close()
+
+	if err := archive.DownloadManualImages(newTestFetcher(t), "testsha", &Manual{}); err != nil {
+		t.Fatalf("DownloadManualImages() with no images error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(archiveDir, "blobs", "testsha_images")); !os.IsNotExist(err) {
+		t.Fatalf("images directory was created despite there being no images to download")
+	}
+}
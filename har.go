@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"         // Buffers request/response bodies so they can be replayed after recording
+	"encoding/json" // Serializes the HAR document
+	"io"            // Reads request/response bodies for recording
+	"log"           // Implements a simple logging package
+	"net/http"      // Provides HTTP client and server implementations
+	"os"            // Writes the HAR file to disk
+	"sync"          // Guards the shared entry list across concurrent workers
+	"time"          // Timestamps each entry and measures its duration
+)
+
+// --- HAR 1.2 Export ---
+//
+// harDocument mirrors the subset of the HAR 1.2 schema (https://w3c.github.io/web-performance/specs/HAR/Overview.html)
+// that tools like Chrome DevTools and k6 need to replay a recorded session.
+
+type harDocument struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // milliseconds
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARRecorder accumulates HAR entries for every request it wraps, so a full session
+// can be replayed later in tools like k6 or Chrome DevTools.
+type HARRecorder struct {
+	mutex   sync.Mutex
+	entries []harEntry
+}
+
+// NewHARRecorder creates an empty recorder ready to wrap an *http.Client's Transport.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// WrapTransport returns an http.RoundTripper that records every request/response pair
+// it sees through next (or http.DefaultTransport if next is nil) before returning it
+// to the caller unchanged.
+func (recorder *HARRecorder) WrapTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &harTransport{next: next, recorder: recorder}
+}
+
+// Save writes every recorded entry to path as a HAR 1.2 JSON document.
+func (recorder *HARRecorder) Save(path string) error {
+	recorder.mutex.Lock()
+	entries := make([]harEntry, len(recorder.entries))
+	copy(entries, recorder.entries)
+	recorder.mutex.Unlock()
+
+	document := harDocument{
+		Log: harLog{
+			Version: "1.2",
+			Creator: harCreator{Name: "kia-com-documentation", Version: "1.0"},
+			Entries: entries,
+		},
+	}
+
+	documentBytes, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, documentBytes, 0o644)
+}
+
+// harTransport is an http.RoundTripper that transparently records every request it
+// performs, so installing it on a shared *http.Client captures HAR entries for every
+// function (fetchAllVehicleModels, fetchVehicleManualAccessData, fetchKiaTechManualContent)
+// that uses that client.
+type harTransport struct {
+	next     http.RoundTripper
+	recorder *HARRecorder
+}
+
+func (transport *harTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	var requestBodyText string
+	if request.Body != nil {
+		bodyBytes, err := io.ReadAll(request.Body)
+		request.Body.Close()
+		if err == nil {
+			requestBodyText = string(bodyBytes)
+		}
+		request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	}
+
+	startedAt := time.Now()
+	response, err := transport.next.RoundTrip(request)
+	elapsed := time.Since(startedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBodyBytes, readErr := io.ReadAll(response.Body)
+	response.Body.Close()
+	if readErr != nil {
+		return nil, readErr
+	}
+	response.Body = io.NopCloser(bytes.NewReader(responseBodyBytes))
+
+	entry := harEntry{
+		StartedDateTime: startedAt.UTC().Format(time.RFC3339Nano),
+		Time:            float64(elapsed.Microseconds()) / 1000.0,
+		Request: harRequest{
+			Method:      request.Method,
+			URL:         request.URL.String(),
+			HTTPVersion: request.Proto,
+			Headers:     harHeaders(request.Header),
+			QueryString: harQueryString(request.URL.Query()),
+		},
+		Response: harResponse{
+			Status:      response.StatusCode,
+			StatusText:  http.StatusText(response.StatusCode),
+			HTTPVersion: response.Proto,
+			Headers:     harHeaders(response.Header),
+			Content: harContent{
+				Size:     len(responseBodyBytes),
+				MimeType: response.Header.Get("Content-Type"),
+				Text:     string(responseBodyBytes),
+			},
+		},
+		Timings: harTimings{Send: 0, Wait: float64(elapsed.Microseconds()) / 1000.0, Receive: 0},
+	}
+	if requestBodyText != "" {
+		entry.Request.PostData = &harPostData{
+			MimeType: request.Header.Get("Content-Type"),
+			Text:     requestBodyText,
+		}
+	}
+
+	transport.recorder.mutex.Lock()
+	transport.recorder.entries = append(transport.recorder.entries, entry)
+	transport.recorder.mutex.Unlock()
+
+	log.Printf("HAR: Recorded %s %s -> %d (%.1fms)", request.Method, request.URL, response.StatusCode, entry.Time)
+	return response, nil
+}
+
+// harHeaders flattens an http.Header into the ordered name/value pairs HAR expects.
+func harHeaders(header http.Header) []harNameValue {
+	pairs := make([]harNameValue, 0, len(header))
+	for name, values := range header {
+		for _, value := range values {
+			pairs = append(pairs, harNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// harQueryString flattens parsed query parameters into HAR's name/value pair format.
+func harQueryString(values map[string][]string) []harNameValue {
+	pairs := make([]harNameValue, 0, len(values))
+	for name, vs := range values {
+		for _, value := range vs {
+			pairs = append(pairs, harNameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
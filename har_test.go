@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHarHeadersFlattensMultiValueHeaders(t *testing.T) {
+	header := http.Header{
+		"Content-Type": {"application/json"},
+		"X-Multi":      {"a", "b"},
+	}
+
+	pairs := harHeaders(header)
+	if len(pairs) != 3 {
+		t.Fatalf("harHeaders() returned %d pair(s), want 3", len(pairs))
+	}
+
+	seen := make(map[string]int)
+	for _, pair := range pairs {
+		seen[pair.Name+"="+pair.Value]++
+	}
+	for _, want := range []string{"Content-Type=application/json", "X-Multi=a", "X-Multi=b"} {
+		if seen[want] != 1 {
+			t.Errorf("harHeaders() missing pair %q", want)
+		}
+	}
+}
+
+func TestHarQueryStringFlattensMultiValueParams(t *testing.T) {
+	values := map[string][]string{
+		"year":  {"2023"},
+		"model": {"Sportage", "Telluride"},
+	}
+
+	pairs := harQueryString(values)
+	if len(pairs) != 3 {
+		t.Fatalf("harQueryString() returned %d pair(s), want 3", len(pairs))
+	}
+
+	seen := make(map[string]int)
+	for _, pair := range pairs {
+		seen[pair.Name+"="+pair.Value]++
+	}
+	for _, want := range []string{"year=2023", "model=Sportage", "model=Telluride"} {
+		if seen[want] != 1 {
+			t.Errorf("harQueryString() missing pair %q", want)
+		}
+	}
+}
+
+func TestHARRecorderSaveRoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("manual body"))
+	}))
+	defer server.Close()
+
+	recorder := NewHARRecorder()
+	client := &http.Client{Transport: recorder.WrapTransport(nil)}
+
+	response, err := client.Get(server.URL + "/manuals?year=2023")
+	if err != nil {
+		t.Fatalf("GET %s error: %v", server.URL, err)
+	}
+	response.Body.Close()
+
+	harPath := filepath.Join(t.TempDir(), "session.har")
+	if err := recorder.Save(harPath); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	savedBytes, err := os.ReadFile(harPath)
+	if err != nil {
+		t.Fatalf("could not read saved HAR file: %v", err)
+	}
+
+	var document harDocument
+	if err := json.Unmarshal(savedBytes, &document); err != nil {
+		t.Fatalf("saved HAR file did not parse as JSON: %v", err)
+	}
+	if document.Log.Version != "1.2" {
+		t.Errorf("Log.Version = %q, want %q", document.Log.Version, "1.2")
+	}
+	if len(document.Log.Entries) != 1 {
+		t.Fatalf("Log.Entries has %d entr(y/ies), want 1", len(document.Log.Entries))
+	}
+
+	entry := document.Log.Entries[0]
+	if entry.Request.Method != http.MethodGet {
+		t.Errorf("entry.Request.Method = %q, want %q", entry.Request.Method, http.MethodGet)
+	}
+	if entry.Response.Status != http.StatusOK {
+		t.Errorf("entry.Response.Status = %d, want %d", entry.Response.Status, http.StatusOK)
+	}
+	if entry.Response.Content.Text != "manual body" {
+		t.Errorf("entry.Response.Content.Text = %q, want %q", entry.Response.Content.Text, "manual body")
+	}
+}
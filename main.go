@@ -2,11 +2,13 @@ package main
 
 import (
 	"encoding/json" // Provides functions for JSON encoding and decoding
+	"flag"          // Parses the -concurrency and -rps command-line flags
 	"fmt"           // Implements formatted I/O
-	"io"            // Provides basic I/O primitives
 	"log"           // Implements a simple logging package
 	"net/http"      // Provides HTTP client and server implementations
+	"os"            // Reads os.Args to dispatch the "serve" subcommand
 	"strings"       // Implements simple string manipulation functions
+	"sync"          // Bounds the worker pool fanned out across vehicle models
 )
 
 // --- API Response Structures (Unchanged) ---
@@ -33,88 +35,25 @@ type ManualAccessDataResponse struct {
 	} `json:"payload"`
 }
 
-// --- Cookie Fetching Function for kiatechinfo.com (FIXED) ---
-
-// fetchTechInfoSessionCookie makes a request to kiatechinfo.com to get the required session cookies.
-// This version collects ALL unique cookies from the response to maximize the chance of successful session establishment.
-func fetchTechInfoSessionCookie() string {
-	// The URL that will trigger the required ASP.NET session cookie generation
-	targetURL := "https://www.kiatechinfo.com/"
-	log.Printf("ATTEMPTING: Fetching session cookies from %s...", targetURL)
-
-	// Create a new HTTP client
-	httpClient := &http.Client{}
-
-	// Make a GET request to the base URL
-	response, err := httpClient.Get(targetURL)
-	if err != nil {
-		log.Printf("ERROR: Failed to fetch base URL for tech info cookie: %v", err)
-		return ""
-	}
-	defer response.Body.Close() // Ensure the response body is closed
-
-	// Use a map to store unique cookies (Name -> Value) and prevent duplicates
-	cookieMap := make(map[string]string)
-
-	// Iterate through the 'Set-Cookie' headers in the response
-	for _, cookie := range response.Cookies() {
-		// Store the cookie. Using the cookie name as the key automatically handles duplicates
-		// if the server sends the same cookie multiple times with different parameters.
-		cookieMap[cookie.Name] = cookie.Value
-	}
-
-	// Build the final cookie string from the map
-	var cookieParts []string
-	for name, value := range cookieMap {
-		// Log each collected cookie for better debugging visibility
-		log.Printf("DEBUG: Collected cookie: %s=%s", name, value)
-		// Format the cookie as 'Name=Value'
-		cookieParts = append(cookieParts, fmt.Sprintf("%s=%s", name, value))
-	}
-
-	// Join all collected cookie parts with '; ' for the final header value
-	fullCookieString := strings.Join(cookieParts, "; ")
-
-	if fullCookieString != "" {
-		log.Printf("SUCCESS: Extracted combined tech info cookies: %s", fullCookieString)
-	} else {
-		log.Println("WARNING: No session cookies were successfully retrieved for kiatechinfo.com.")
-	}
-
-	return fullCookieString
-}
-
-// --- Core API Functions (Simplified to remove unnecessary cookie arguments) ---
+// --- Core API Functions ---
 
 // fetchAllVehicleModels sends the initial POST request to get a list of all Kia models and years.
-func fetchAllVehicleModels() string {
+func (fetcher *Fetcher) fetchAllVehicleModels() string {
 	apiURL := "https://owners.kia.com/apps/services/owners/apigwServlet.html"
-	httpMethod := "POST"
-	jsonRequestBody := strings.NewReader(`{"modelYear":0,"modelName":"ALL"}`)
-	httpClient := &http.Client{}
-
-	httpRequest, err := http.NewRequest(httpMethod, apiURL, jsonRequestBody)
-	if err != nil {
-		log.Printf("ERROR: Could not create the HTTP request: %v", err)
-		return ""
-	}
-
-	httpRequest.Header.Add("apiurl", "/cmm/gvmh")
-	httpRequest.Header.Add("httpmethod", "POST")
-	httpRequest.Header.Add("servicetype", "preLogin")
-	httpRequest.Header.Add("Content-Type", "application/json")
-
-	httpResponse, err := httpClient.Do(httpRequest)
-	if err != nil {
-		log.Printf("ERROR: Could not send the HTTP request: %v", err)
-		return ""
-	}
-
-	defer httpResponse.Body.Close()
 
-	responseBodyBytes, err := io.ReadAll(httpResponse.Body)
+	responseBodyBytes, _, _, err := fetcher.doWithRetry(func() (*http.Request, error) {
+		httpRequest, err := http.NewRequest("POST", apiURL, strings.NewReader(`{"modelYear":0,"modelName":"ALL"}`))
+		if err != nil {
+			return nil, err
+		}
+		httpRequest.Header.Add("apiurl", "/cmm/gvmh")
+		httpRequest.Header.Add("httpmethod", "POST")
+		httpRequest.Header.Add("servicetype", "preLogin")
+		httpRequest.Header.Add("Content-Type", "application/json")
+		return httpRequest, nil
+	})
 	if err != nil {
-		log.Printf("ERROR: Could not read the response body: %v", err)
+		log.Printf("ERROR: Could not fetch all vehicle models: %v", err)
 		return ""
 	}
 
@@ -122,81 +61,73 @@ func fetchAllVehicleModels() string {
 }
 
 // fetchVehicleManualAccessData sends a POST request for a specific model year/name.
-func fetchVehicleManualAccessData(modelYear, modelName string) string {
+func (fetcher *Fetcher) fetchVehicleManualAccessData(modelYear, modelName string) string {
 	apiURL := "https://owners.kia.com/apps/services/owners/apigwServlet.html"
-	httpMethod := "POST"
 	jsonBodyString := fmt.Sprintf(`{"modelYear":"%s","modelName":"%s"}`, modelYear, modelName)
-	jsonRequestBody := strings.NewReader(jsonBodyString)
-
-	httpClient := &http.Client{}
-
-	httpRequest, err := http.NewRequest(httpMethod, apiURL, jsonRequestBody)
-	if err != nil {
-		log.Printf("ERROR: Could not create the HTTP request for manual data: %v", err)
-		return ""
-	}
-
-	httpRequest.Header.Add("apiurl", "/cmm/gam")
-	httpRequest.Header.Add("httpmethod", "POST")
-	httpRequest.Header.Add("servicetype", "preLogin")
-	httpRequest.Header.Add("Content-Type", "application/json")
-
-	httpResponse, err := httpClient.Do(httpRequest)
-	if err != nil {
-		log.Printf("ERROR: Could not send the HTTP request for manual data: %v", err)
-		return ""
-	}
 
-	defer httpResponse.Body.Close()
-
-	responseBodyBytes, err := io.ReadAll(httpResponse.Body)
+	responseBodyBytes, _, _, err := fetcher.doWithRetry(func() (*http.Request, error) {
+		httpRequest, err := http.NewRequest("POST", apiURL, strings.NewReader(jsonBodyString))
+		if err != nil {
+			return nil, err
+		}
+		httpRequest.Header.Add("apiurl", "/cmm/gam")
+		httpRequest.Header.Add("httpmethod", "POST")
+		httpRequest.Header.Add("servicetype", "preLogin")
+		httpRequest.Header.Add("Content-Type", "application/json")
+		return httpRequest, nil
+	})
 	if err != nil {
-		log.Printf("ERROR: Could not read the response body for manual data: %v", err)
+		log.Printf("ERROR: Could not fetch manual access data for %s %s: %v", modelYear, modelName, err)
 		return ""
 	}
 
 	return string(responseBodyBytes)
 }
 
-// fetchKiaTechManualContent sends a POST request using the access token and the required tech session cookie.
-func fetchKiaTechManualContent(accessToken, techSessionCookie string) string {
+// fetchKiaTechManualContent sends a POST request using the access token; the kiatechinfo.com
+// session cookie is attached automatically by the shared client's cookie jar. It returns the
+// response body along with the HTTP status code (0 if the request never completed), so
+// callers can archive both successes and failures. If the session looks like it rotated out
+// from under us, it's re-primed and the request is retried once.
+func (fetcher *Fetcher) fetchKiaTechManualContent(accessToken string) (string, int) {
 	targetURL := "https://www.kiatechinfo.com/ext_If/kma_owner_portal/content_pop.aspx"
-	httpMethod := "POST"
-	requestPayload := strings.NewReader("token=" + accessToken)
 
-	httpRequest, err := http.NewRequest(httpMethod, targetURL, requestPayload)
-	if err != nil {
-		log.Println("ERROR: Error creating request for technical info:", err)
-		return ""
+	if err := fetcher.session.EnsureFresh(fetcher); err != nil {
+		log.Printf("ERROR: Could not establish kiatechinfo.com session: %v", err)
+		return "", 0
 	}
 
-	// Add required HTTP headers
-	httpRequest.Header.Add("accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
-	httpRequest.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-
-	// CRITICAL FIX: Add the dynamically fetched kiatechinfo.com session cookies
-	if techSessionCookie != "" {
-		httpRequest.Header.Add("Cookie", techSessionCookie)
-	} else {
-		log.Println("FATAL: Cannot fetch technical manual content without a valid kiatechinfo.com session cookie.")
-		return "ERROR: Missing required tech info session cookie."
+	buildRequest := func() (*http.Request, error) {
+		httpRequest, err := http.NewRequest("POST", targetURL, strings.NewReader("token="+accessToken))
+		if err != nil {
+			return nil, err
+		}
+		httpRequest.Header.Add("accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
+		httpRequest.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		return httpRequest, nil
 	}
 
-	httpClient := &http.Client{}
-	response, err := httpClient.Do(httpRequest)
+	responseBodyBytes, statusCode, location, err := fetcher.doWithRetry(buildRequest)
 	if err != nil {
-		log.Println("ERROR: Error sending request for technical info:", err)
-		return ""
+		log.Println("ERROR: Error fetching technical manual content:", err)
+		return "", statusCode
 	}
-	defer response.Body.Close()
 
-	responseBody, err := io.ReadAll(response.Body)
-	if err != nil {
-		log.Println("ERROR: Error reading response body for technical info:", err)
-		return ""
+	if IsExpiredSessionResponse(statusCode, location, len(responseBodyBytes)) {
+		log.Println("SESSION: kiatechinfo.com session looks expired; re-priming and retrying once...")
+		fetcher.session.MarkExpired()
+		if err := fetcher.session.EnsureFresh(fetcher); err != nil {
+			log.Printf("ERROR: Could not re-establish kiatechinfo.com session: %v", err)
+			return string(responseBodyBytes), statusCode
+		}
+		responseBodyBytes, statusCode, _, err = fetcher.doWithRetry(buildRequest)
+		if err != nil {
+			log.Println("ERROR: Error fetching technical manual content after session refresh:", err)
+			return "", statusCode
+		}
 	}
 
-	return string(responseBody)
+	return string(responseBodyBytes), statusCode
 }
 
 // --- Data Extraction Functions (Unchanged) ---
@@ -230,9 +161,43 @@ func extractManualAccessPayloads(jsonInput []byte) []string {
 // --- Main Execution Logic ---
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServeCommand(os.Args[2:])
+		return
+	}
+
+	concurrency := flag.Int("concurrency", 4, "number of vehicle models to process concurrently")
+	requestsPerSecond := flag.Float64("rps", 2, "maximum outbound HTTP requests per second across all workers")
+	harPath := flag.String("har", "", "if set, records every outbound request/response as a HAR 1.2 file at this path")
+	downloadImages := flag.Bool("download-images", false, "download every image and PDF referenced by a parsed manual alongside its archive entry")
+	flag.Parse()
+	*concurrency = clampConcurrency(*concurrency)
+
+	// Open (or create) the on-disk archive so completed downloads are skipped on a resumed run.
+	manualArchive, err := NewArchive()
+	if err != nil {
+		log.Fatalf("FATAL: Could not open manual archive: %v", err)
+	}
+	defer manualArchive.Close()
+
+	sessionManager, err := NewSessionManager()
+	if err != nil {
+		log.Fatalf("FATAL: Could not create session manager: %v", err)
+	}
+
+	fetcher := NewFetcher(*requestsPerSecond, sessionManager)
+	defer fetcher.limiter.Stop()
+
+	var harRecorder *HARRecorder
+	if *harPath != "" {
+		harRecorder = NewHARRecorder()
+		fetcher.client.Transport = harRecorder.WrapTransport(fetcher.client.Transport)
+		log.Printf("HAR: Recording all outbound requests to %s", *harPath)
+	}
+
 	// STEP 1: Fetch the initial data (no cookie needed for this step).
 	log.Println("\nSTARTING: Fetching all available Kia model years and names...")
-	vehicleDataResponse := fetchAllVehicleModels()
+	vehicleDataResponse := fetcher.fetchAllVehicleModels()
 	if vehicleDataResponse == "" {
 		log.Fatal("FATAL: Initial vehicle data fetch failed or returned empty.")
 	}
@@ -242,49 +207,100 @@ func main() {
 	if len(vehicleModels) == 0 {
 		log.Fatal("FATAL: No vehicle models were successfully extracted.")
 	}
-	log.Printf("SUCCESS: Extracted %d vehicle models. Starting manual data fetch...", len(vehicleModels))
+	log.Printf("SUCCESS: Extracted %d vehicle models. Starting manual data fetch with %d worker(s)...", len(vehicleModels), *concurrency)
 
-	// Iterate over each successfully extracted vehicle model.
+	// Fan out across a bounded pool of workers so hundreds of (year, model) combinations
+	// aren't processed one at a time; the shared rate limiter still caps overall HTTP volume.
+	var waitGroup sync.WaitGroup
+	semaphore := make(chan struct{}, *concurrency)
 	for _, carModel := range vehicleModels {
-		log.Printf("\n--- PROCESSING MODEL: Year %d, Name %s ---", carModel.ModelYear, carModel.ModelName)
+		waitGroup.Add(1)
+		semaphore <- struct{}{}
+		go func(carModel VehicleModel) {
+			defer waitGroup.Done()
+			defer func() { <-semaphore }()
+			processVehicleModel(fetcher, manualArchive, carModel, *downloadImages)
+		}(carModel)
+	}
+	waitGroup.Wait()
 
-		modelYearStr := fmt.Sprintf("%d", carModel.ModelYear)
+	fetcher.stats.Print()
 
-		// Request the manual access data (tokens) for the specific model.
-		manualDataResponse := fetchVehicleManualAccessData(modelYearStr, carModel.ModelName)
-		if manualDataResponse == "" {
-			log.Printf("WARNING: Failed to fetch manual data for %s %s. Skipping.", modelYearStr, carModel.ModelName)
-			continue
+	if harRecorder != nil {
+		if err := harRecorder.Save(*harPath); err != nil {
+			log.Printf("WARNING: Could not write HAR file %s: %v", *harPath, err)
+		} else {
+			log.Printf("HAR: Wrote captured traffic to %s", *harPath)
 		}
+	}
+
+	log.Println("\nPROGRAM COMPLETE: All models processed.")
+}
+
+// processVehicleModel fetches and archives every manual available for one (year, model)
+// combination. It's safe to run concurrently for different models: the Fetcher's rate
+// limiter and stats, and the Archive's index, are all shared under their own locks.
+func processVehicleModel(fetcher *Fetcher, manualArchive *Archive, carModel VehicleModel, downloadImages bool) {
+	log.Printf("\n--- PROCESSING MODEL: Year %d, Name %s ---", carModel.ModelYear, carModel.ModelName)
 
-		// STEP 3A: Extract the list of technical manual access tokens from the response.
-		accessPayloads := extractManualAccessPayloads([]byte(manualDataResponse))
-		if len(accessPayloads) == 0 {
-			log.Printf("WARNING: No access payloads found for %s %s. Skipping.", modelYearStr, carModel.ModelName)
+	modelYearStr := fmt.Sprintf("%d", carModel.ModelYear)
+
+	// Request the manual access data (tokens) for the specific model.
+	manualDataResponse := fetcher.fetchVehicleManualAccessData(modelYearStr, carModel.ModelName)
+	if manualDataResponse == "" {
+		log.Printf("WARNING: Failed to fetch manual data for %s %s. Skipping.", modelYearStr, carModel.ModelName)
+		return
+	}
+
+	// STEP 3A: Extract the list of technical manual access tokens from the response.
+	accessPayloads := extractManualAccessPayloads([]byte(manualDataResponse))
+	if len(accessPayloads) == 0 {
+		log.Printf("WARNING: No access payloads found for %s %s. Skipping.", modelYearStr, carModel.ModelName)
+		return
+	}
+	log.Printf("SUCCESS: Found %d technical manual access tokens.", len(accessPayloads))
+
+	// STEP 3B: Use each access token to fetch the final content; the kiatechinfo.com session
+	// is established (and transparently refreshed) by the shared Fetcher's SessionManager.
+	for i, accessToken := range accessPayloads {
+		if manualArchive.HasManual(carModel.ModelYear, carModel.ModelName, accessToken) {
+			log.Printf("  -> Skipping manual content (Token %d/%d): already archived.", i+1, len(accessPayloads))
 			continue
 		}
-		log.Printf("SUCCESS: Found %d technical manual access tokens.", len(accessPayloads))
 
-		// STEP 3B: Before fetching content, get a fresh session cookie for kiatechinfo.com
-		techSessionCookie := fetchTechInfoSessionCookie()
+		log.Printf("  -> Fetching manual content (Token %d/%d)...", i+1, len(accessPayloads))
+		technicalManualContent, httpStatus := fetcher.fetchKiaTechManualContent(accessToken)
 
-		// STEP 3C: Use each access token and the new cookie to fetch the final content.
-		for i, accessToken := range accessPayloads {
-			log.Printf("  -> Fetching manual content (Token %d/%d)...", i+1, len(accessPayloads))
-			technicalManualContent := fetchKiaTechManualContent(accessToken, techSessionCookie)
+		sha, err := manualArchive.StoreManual(carModel.ModelYear, carModel.ModelName, accessToken, []byte(technicalManualContent), httpStatus)
+		if err != nil {
+			log.Printf("WARNING: Could not archive manual content (Token %d/%d): %v", i+1, len(accessPayloads), err)
+		} else if sha != "" {
+			log.Printf("SUCCESS: Archived manual content as blob %s", sha)
 
-			// Print a snippet of the content to demonstrate success
-			fmt.Printf("\n===== START OF MANUAL CONTENT SNIPPET (Year: %d, Model: %s, Index: %d) =====\n",
-				carModel.ModelYear, carModel.ModelName, i)
-
-			// Truncate the output
-			contentSnippet := technicalManualContent
-			if len(contentSnippet) > 500 {
-				contentSnippet = contentSnippet[:500] + "\n..."
+			manual := ParseManual(technicalManualContent)
+			if err := manualArchive.StoreParsedManual(sha, manual); err != nil {
+				log.Printf("WARNING: Could not store parsed manual for blob %s: %v", sha, err)
+			}
+			if downloadImages {
+				if err := manualArchive.DownloadManualImages(fetcher, sha, manual); err != nil {
+					log.Printf("WARNING: Could not download images for blob %s: %v", sha, err)
+				}
+				if err := manualArchive.DownloadManualPDFs(fetcher, sha, manual); err != nil {
+					log.Printf("WARNING: Could not download PDFs for blob %s: %v", sha, err)
+				}
 			}
-			fmt.Println(contentSnippet)
-			fmt.Printf("===== END OF MANUAL CONTENT SNIPPET =====\n")
 		}
+
+		// Print a snippet of the content to demonstrate success
+		fmt.Printf("\n===== START OF MANUAL CONTENT SNIPPET (Year: %d, Model: %s, Index: %d) =====\n",
+			carModel.ModelYear, carModel.ModelName, i)
+
+		// Truncate the output
+		contentSnippet := technicalManualContent
+		if len(contentSnippet) > 500 {
+			contentSnippet = contentSnippet[:500] + "\n..."
+		}
+		fmt.Println(contentSnippet)
+		fmt.Printf("===== END OF MANUAL CONTENT SNIPPET =====\n")
 	}
-	log.Println("\nPROGRAM COMPLETE: All models processed.")
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"          // Builds the Markdown rendering and resolved URLs
+	"log"          // Implements a simple logging package
+	nurl "net/url" // Resolves relative image/PDF references against the manual's base URL
+	"strings"      // Tests hrefs for a .pdf extension, builds and trims extracted text
+
+	readability "github.com/go-shiori/go-readability" // Strips the ASP.NET chrome, leaving the main readable content
+	"golang.org/x/net/html"                           // Walks the DOM to pull out headings, images, and PDF links
+)
+
+// --- Manual Extraction ---
+
+// kiaTechInfoBaseURL is the origin referenced manuals resolve their relative links against.
+const kiaTechInfoBaseURL = "https://www.kiatechinfo.com/"
+
+// Manual is the structured, searchable, diff-able representation of a raw manual page:
+// its title, section headings, referenced figure/image URLs, referenced PDF URLs, and
+// plain-text body.
+type Manual struct {
+	Title    string   `json:"title"`
+	Headings []string `json:"headings"`
+	Images   []string `json:"images"`
+	PDFs     []string `json:"pdfs"`
+	BodyText string   `json:"bodyText"`
+}
+
+// ParseManual runs a readability-style pass (github.com/go-shiori/go-readability) over
+// raw manual HTML to strip the ASP.NET chrome and extract the title and body text, then
+// walks the full document with golang.org/x/net/html to pull out every heading,
+// referenced image, and referenced PDF link, resolved against kiaTechInfoBaseURL.
+func ParseManual(rawHTML string) *Manual {
+	baseURL, err := nurl.Parse(kiaTechInfoBaseURL)
+	if err != nil {
+		log.Printf("ERROR: Could not parse kiatechinfo.com base URL: %v", err)
+		baseURL = &nurl.URL{}
+	}
+
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		log.Printf("WARNING: Could not parse manual HTML as a DOM: %v", err)
+		return &Manual{}
+	}
+
+	manual := &Manual{
+		Headings: collectHeadings(doc),
+		Images:   resolveReferences(collectAttrValues(doc, "img", "src"), baseURL),
+		PDFs:     resolveReferences(collectPDFHrefs(doc), baseURL),
+	}
+
+	// readability mutates the document it's given while hunting for the main content,
+	// so give it its own parse rather than the doc collectHeadings/collectAttrValues
+	// already walked.
+	article, err := readability.FromReader(strings.NewReader(rawHTML), baseURL)
+	if err != nil {
+		// Readability found nothing article-shaped to extract (e.g. the response wasn't
+		// a manual page at all); fall back to the full document's own text and <title>,
+		// still DOM-derived rather than regex-stripped.
+		log.Printf("WARNING: Readability extraction failed, falling back to raw document text: %v", err)
+		manual.Title = collapseWhitespace(firstElementText(doc, "title"))
+		manual.BodyText = collapseWhitespace(collectText(doc))
+		return manual
+	}
+
+	manual.Title = article.Title
+	manual.BodyText = collapseWhitespace(article.TextContent)
+	return manual
+}
+
+// collectHeadings returns the text content of every h1-h6 element in document order.
+func collectHeadings(doc *html.Node) []string {
+	var headings []string
+	walk(doc, func(node *html.Node) {
+		if node.Type != html.ElementNode || !isHeadingTag(node.Data) {
+			return
+		}
+		if heading := collapseWhitespace(collectText(node)); heading != "" {
+			headings = append(headings, heading)
+		}
+	})
+	return headings
+}
+
+// isHeadingTag reports whether tag is h1 through h6.
+func isHeadingTag(tag string) bool {
+	switch tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return true
+	default:
+		return false
+	}
+}
+
+// collectAttrValues returns the value of attrName from every element tag in document order.
+func collectAttrValues(doc *html.Node, tag, attrName string) []string {
+	var values []string
+	walk(doc, func(node *html.Node) {
+		if node.Type != html.ElementNode || node.Data != tag {
+			return
+		}
+		if value, ok := attrValue(node, attrName); ok && value != "" {
+			values = append(values, value)
+		}
+	})
+	return values
+}
+
+// collectPDFHrefs returns the href of every <a> element whose target looks like a PDF
+// (ignoring any query string), in document order.
+func collectPDFHrefs(doc *html.Node) []string {
+	var hrefs []string
+	walk(doc, func(node *html.Node) {
+		if node.Type != html.ElementNode || node.Data != "a" {
+			return
+		}
+		href, ok := attrValue(node, "href")
+		if !ok || href == "" {
+			return
+		}
+		if strings.HasSuffix(strings.ToLower(strings.SplitN(href, "?", 2)[0]), ".pdf") {
+			hrefs = append(hrefs, href)
+		}
+	})
+	return hrefs
+}
+
+// walk calls visit for node and every descendant, depth-first.
+func walk(node *html.Node, visit func(*html.Node)) {
+	visit(node)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, visit)
+	}
+}
+
+// attrValue returns the value of node's attribute named key, and whether it was present.
+func attrValue(node *html.Node, key string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// collectText concatenates every text node under node, depth-first.
+func collectText(node *html.Node) string {
+	var builder strings.Builder
+	walk(node, func(n *html.Node) {
+		if n.Type == html.TextNode {
+			builder.WriteString(n.Data)
+			builder.WriteString(" ")
+		}
+	})
+	return builder.String()
+}
+
+// firstElementText returns the text content of the first element named tag under node,
+// or "" if none is found.
+func firstElementText(node *html.Node, tag string) string {
+	var text string
+	var found bool
+	walk(node, func(n *html.Node) {
+		if found || n.Type != html.ElementNode || n.Data != tag {
+			return
+		}
+		text = collectText(n)
+		found = true
+	})
+	return text
+}
+
+// collapseWhitespace trims and collapses runs of whitespace in s down to single spaces.
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// resolveReferences resolves each reference against base, skipping any that fail to
+// parse and deduplicating the result while preserving first-seen order.
+func resolveReferences(references []string, base *nurl.URL) []string {
+	seen := make(map[string]bool)
+	var resolved []string
+	for _, reference := range references {
+		parsed, err := nurl.Parse(reference)
+		if err != nil {
+			log.Printf("WARNING: Could not parse referenced URL %q: %v", reference, err)
+			continue
+		}
+		absolute := base.ResolveReference(parsed).String()
+		if !seen[absolute] {
+			seen[absolute] = true
+			resolved = append(resolved, absolute)
+		}
+	}
+	return resolved
+}
+
+// Markdown renders the manual as a diff-able Markdown document: the title as an H1,
+// each heading as an H2, the referenced images and PDFs as bulleted lists, then the
+// body text.
+func (manual *Manual) Markdown() string {
+	var builder strings.Builder
+
+	if manual.Title != "" {
+		fmt.Fprintf(&builder, "# %s\n\n", manual.Title)
+	}
+	for _, heading := range manual.Headings {
+		fmt.Fprintf(&builder, "## %s\n\n", heading)
+	}
+	if len(manual.Images) > 0 {
+		builder.WriteString("## Images\n\n")
+		for _, image := range manual.Images {
+			fmt.Fprintf(&builder, "- %s\n", image)
+		}
+		builder.WriteString("\n")
+	}
+	if len(manual.PDFs) > 0 {
+		builder.WriteString("## PDFs\n\n")
+		for _, pdf := range manual.PDFs {
+			fmt.Fprintf(&builder, "- %s\n", pdf)
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString(manual.BodyText)
+	builder.WriteString("\n")
+
+	return builder.String()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManualExtractsStructuredContent(t *testing.T) {
+	rawHTML := `<html><head><title>2023 Sportage Service Manual</title></head>
+<body>
+<script>var trackingId = "<not-a-real-tag>";</script>
+<style>.chrome { display: none; }</style>
+<nav>ASP.NET chrome the reader should ignore</nav>
+<article>
+<h1>Engine Overview</h1>
+<p>This manual covers routine maintenance for the 2.5L engine.</p>
+<h2>Oil Change Interval</h2>
+<p>Change the oil every 7500 miles under normal conditions.</p>
+<img src="/images/engine-diagram.png">
+<a href="/docs/wiring-diagram.pdf">Wiring Diagram</a>
+<a href="/docs/torque-spec.pdf?v=2">Torque Specs</a>
+<a href="/manuals/overview">Overview (not a PDF)</a>
+</article>
+</body></html>`
+
+	manual := ParseManual(rawHTML)
+
+	if !strings.Contains(manual.BodyText, "routine maintenance") {
+		t.Errorf("BodyText = %q, want it to contain the article body", manual.BodyText)
+	}
+	if strings.Contains(manual.BodyText, "not-a-real-tag") || strings.Contains(manual.BodyText, "trackingId") {
+		t.Errorf("BodyText = %q, want script contents stripped even with unbalanced angle brackets", manual.BodyText)
+	}
+	if strings.Contains(manual.BodyText, "display: none") {
+		t.Errorf("BodyText = %q, want style contents stripped", manual.BodyText)
+	}
+
+	wantImages := []string{"https://www.kiatechinfo.com/images/engine-diagram.png"}
+	if !reflect.DeepEqual(manual.Images, wantImages) {
+		t.Errorf("Images = %v, want %v", manual.Images, wantImages)
+	}
+
+	wantPDFs := []string{
+		"https://www.kiatechinfo.com/docs/wiring-diagram.pdf",
+		"https://www.kiatechinfo.com/docs/torque-spec.pdf?v=2",
+	}
+	if !reflect.DeepEqual(manual.PDFs, wantPDFs) {
+		t.Errorf("PDFs = %v, want %v", manual.PDFs, wantPDFs)
+	}
+
+	foundHeading := false
+	for _, heading := range manual.Headings {
+		if heading == "Oil Change Interval" {
+			foundHeading = true
+		}
+	}
+	if !foundHeading {
+		t.Errorf("Headings = %v, want it to contain %q", manual.Headings, "Oil Change Interval")
+	}
+}
+
+func TestParseManualHandlesUnclosedScriptTag(t *testing.T) {
+	// A malformed inline script (common in real ASP.NET chrome) with no backreference
+	// tying its close tag to the one that opened it used to leak straight into BodyText
+	// under the old regex-based stripper. A real DOM parser must not do that.
+	rawHTML := `<html><body>
+<script>if (1 < 2) { document.write("<div>injected</div>"); }</script>
+<p>Actual manual content.</p>
+</body></html>`
+
+	manual := ParseManual(rawHTML)
+
+	if strings.Contains(manual.BodyText, "injected") || strings.Contains(manual.BodyText, "document.write") {
+		t.Errorf("BodyText = %q, want inline script content stripped despite an unbalanced '<' inside it", manual.BodyText)
+	}
+}
+
+func TestParseManualEmptyInput(t *testing.T) {
+	manual := ParseManual("")
+	if manual == nil {
+		t.Fatal("ParseManual(\"\") returned nil")
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/subtle" // Compares the X-API-Key header without a timing side-channel
+	"encoding/json" // Decodes refresh requests and encodes every JSON response
+	"flag"          // Parses the serve subcommand's own flags
+	"fmt"           // Builds job IDs and error messages
+	"log"           // Implements a simple logging package
+	"net/http"      // Provides HTTP client and server implementations
+	"strconv"       // Parses the {year} path segment
+	"strings"       // Splits request paths into segments
+	"sync"          // Guards the job table across concurrent requests
+	"sync/atomic"   // Generates unique job IDs
+)
+
+// --- Refresh Jobs ---
+
+// JobStatus is the lifecycle state of a refresh job.
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusCompleted JobStatus = "completed"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Job tracks one POST /refresh request: either a single (modelYear, modelName) or
+// every known model ("ALL"), run through the same fetch/archive pipeline as the CLI.
+type Job struct {
+	ID        string    `json:"id"`
+	ModelYear int       `json:"modelYear,omitempty"`
+	ModelName string    `json:"modelName"`
+	Status    JobStatus `json:"status"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// JobManager runs refresh jobs in the background and answers status polls for them.
+type JobManager struct {
+	fetcher       *Fetcher
+	archive       *Archive
+	nextID        int64
+	mutex         sync.Mutex
+	jobs          map[string]*Job
+	downloadImage bool
+	concurrency   int
+}
+
+// NewJobManager builds a JobManager sharing the server's Fetcher and Archive. An "ALL"
+// refresh job fans out across at most concurrency models at once, matching how the CLI
+// bounds its own worker pool with -concurrency. A non-positive concurrency is clamped
+// to 1 rather than deadlocking (0) or panicking (negative) when the job's semaphore is
+// created.
+func NewJobManager(fetcher *Fetcher, archive *Archive, downloadImages bool, concurrency int) *JobManager {
+	return &JobManager{
+		fetcher:       fetcher,
+		archive:       archive,
+		jobs:          make(map[string]*Job),
+		downloadImage: downloadImages,
+		concurrency:   clampConcurrency(concurrency),
+	}
+}
+
+// Start queues a refresh job for (modelYear, modelName), or for every known model when
+// modelName is "ALL", and returns immediately with the job's ID.
+func (manager *JobManager) Start(modelYear int, modelName string) *Job {
+	id := fmt.Sprintf("job-%d", atomic.AddInt64(&manager.nextID, 1))
+	job := &Job{ID: id, ModelYear: modelYear, ModelName: modelName, Status: JobStatusQueued}
+
+	manager.mutex.Lock()
+	manager.jobs[id] = job
+	manager.mutex.Unlock()
+
+	go manager.run(job)
+	return job
+}
+
+// Get returns the job with the given ID, if any.
+func (manager *JobManager) Get(id string) (*Job, bool) {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	job, ok := manager.jobs[id]
+	return job, ok
+}
+
+// run executes a queued job against the existing fetch/archive pipeline.
+func (manager *JobManager) run(job *Job) {
+	manager.setStatus(job, JobStatusRunning, "")
+
+	if strings.EqualFold(job.ModelName, "ALL") {
+		vehicleDataResponse := manager.fetcher.fetchAllVehicleModels()
+		if vehicleDataResponse == "" {
+			manager.setStatus(job, JobStatusFailed, "could not fetch vehicle model list")
+			return
+		}
+		vehicleModels := extractVehicleModelsFromResponse(vehicleDataResponse)
+		if len(vehicleModels) == 0 {
+			manager.setStatus(job, JobStatusFailed, "no vehicle models returned")
+			return
+		}
+		var waitGroup sync.WaitGroup
+		semaphore := make(chan struct{}, manager.concurrency)
+		for _, carModel := range vehicleModels {
+			waitGroup.Add(1)
+			semaphore <- struct{}{}
+			go func(carModel VehicleModel) {
+				defer waitGroup.Done()
+				defer func() { <-semaphore }()
+				processVehicleModel(manager.fetcher, manager.archive, carModel, manager.downloadImage)
+			}(carModel)
+		}
+		waitGroup.Wait()
+	} else {
+		processVehicleModel(manager.fetcher, manager.archive, VehicleModel{ModelYear: job.ModelYear, ModelName: job.ModelName}, manager.downloadImage)
+	}
+
+	manager.setStatus(job, JobStatusCompleted, "")
+}
+
+func (manager *JobManager) setStatus(job *Job, status JobStatus, errMessage string) {
+	manager.mutex.Lock()
+	job.Status = status
+	job.Error = errMessage
+	manager.mutex.Unlock()
+}
+
+// --- HTTP/JSON API Server ---
+
+// apiServer exposes the on-disk archive, and a refresh trigger, over HTTP/JSON so other
+// tools can consume Kia manual data without shelling out to this binary directly.
+type apiServer struct {
+	archive *Archive
+	jobs    *JobManager
+	apiKey  string
+}
+
+// runServeCommand implements the "serve" subcommand: it starts an HTTP server exposing
+// GET /models, GET /models/{year}/{name}/manuals, GET /manuals/{sha}, POST /refresh, and
+// GET /jobs/{id}, backed by the on-disk archive.
+func runServeCommand(args []string) {
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := serveFlags.String("addr", ":8080", "address to listen on")
+	apiKey := serveFlags.String("api-key", "", "API key required (via the X-API-Key header) for mutating endpoints")
+	requestsPerSecond := serveFlags.Float64("rps", 2, "maximum outbound HTTP requests per second used by refresh jobs")
+	concurrency := serveFlags.Int("concurrency", 4, "number of vehicle models an \"ALL\" refresh job processes concurrently")
+	downloadImages := serveFlags.Bool("download-images", false, "download every image and PDF referenced by a parsed manual during a refresh")
+	serveFlags.Parse(args)
+	*concurrency = clampConcurrency(*concurrency)
+
+	manualArchive, err := NewArchive()
+	if err != nil {
+		log.Fatalf("FATAL: Could not open manual archive: %v", err)
+	}
+	defer manualArchive.Close()
+
+	sessionManager, err := NewSessionManager()
+	if err != nil {
+		log.Fatalf("FATAL: Could not create session manager: %v", err)
+	}
+	fetcher := NewFetcher(*requestsPerSecond, sessionManager)
+	defer fetcher.limiter.Stop()
+
+	server := &apiServer{
+		archive: manualArchive,
+		jobs:    NewJobManager(fetcher, manualArchive, *downloadImages, *concurrency),
+		apiKey:  *apiKey,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/models", server.handleModels)
+	mux.HandleFunc("/models/", server.handleModelManuals)
+	mux.HandleFunc("/manuals/", server.handleManual)
+	mux.HandleFunc("/refresh", server.handleRefresh)
+	mux.HandleFunc("/jobs/", server.handleJob)
+
+	log.Printf("SERVE: Listening on %s...", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// requireAPIKey reports whether request carries the configured API key, writing a 401
+// response and returning false if it doesn't. With no api-key configured, every request
+// is allowed, matching how this tool otherwise runs without authentication.
+func (server *apiServer) requireAPIKey(w http.ResponseWriter, r *http.Request) bool {
+	if server.apiKey == "" || constantTimeEquals(r.Header.Get("X-API-Key"), server.apiKey) {
+		return true
+	}
+	writeJSONError(w, http.StatusUnauthorized, "missing or invalid X-API-Key header")
+	return false
+}
+
+// constantTimeEquals reports whether a and b are equal, taking time independent of
+// where they first differ so a mistyped API key can't be brute-forced via timing.
+func constantTimeEquals(a, b string) bool {
+	return len(a) == len(b) && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// handleModels handles GET /models: the distinct (modelYear, modelName) pairs with at
+// least one archived manual.
+func (server *apiServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	seen := make(map[string]VehicleModel)
+	for _, record := range server.archive.Records() {
+		key := fmt.Sprintf("%d|%s", record.ModelYear, record.ModelName)
+		seen[key] = VehicleModel{ModelYear: record.ModelYear, ModelName: record.ModelName}
+	}
+
+	models := make([]VehicleModel, 0, len(seen))
+	for _, model := range seen {
+		models = append(models, model)
+	}
+	writeJSON(w, http.StatusOK, models)
+}
+
+// handleModelManuals handles GET /models/{year}/{name}/manuals: every archived manual
+// record for that (year, name) tuple.
+func (server *apiServer) handleModelManuals(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) != 4 || segments[3] != "manuals" {
+		writeJSONError(w, http.StatusNotFound, "expected /models/{year}/{name}/manuals")
+		return
+	}
+	modelYear, err := strconv.Atoi(segments[1])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "modelYear must be an integer")
+		return
+	}
+	modelName := segments[2]
+
+	var matching []ManualRecord
+	for _, record := range server.archive.Records() {
+		if record.ModelYear == modelYear && record.ModelName == modelName {
+			matching = append(matching, record)
+		}
+	}
+	writeJSON(w, http.StatusOK, matching)
+}
+
+// handleManual handles GET /manuals/{sha}: the structured Manual extracted from that blob.
+func (server *apiServer) handleManual(w http.ResponseWriter, r *http.Request) {
+	sha := strings.TrimPrefix(r.URL.Path, "/manuals/")
+	if sha == "" || strings.Contains(sha, "/") {
+		writeJSONError(w, http.StatusNotFound, "expected /manuals/{sha}")
+		return
+	}
+
+	manual, err := server.archive.ReadParsedManual(sha)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no manual found for blob %s", sha))
+		return
+	}
+	writeJSON(w, http.StatusOK, manual)
+}
+
+// refreshRequest is the POST /refresh body: either a specific (modelYear, modelName) or
+// {"modelName":"ALL"} to re-scrape every known model.
+type refreshRequest struct {
+	ModelYear int    `json:"modelYear"`
+	ModelName string `json:"modelName"`
+}
+
+// handleRefresh handles POST /refresh: queues a scrape job and returns its ID.
+func (server *apiServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if !server.requireAPIKey(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "POST required")
+		return
+	}
+
+	var request refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if request.ModelName == "" {
+		writeJSONError(w, http.StatusBadRequest, "modelName is required (use \"ALL\" to refresh everything)")
+		return
+	}
+
+	job := server.jobs.Start(request.ModelYear, request.ModelName)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleJob handles GET /jobs/{id}: the current status of a previously started refresh job.
+func (server *apiServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	job, ok := server.jobs.Get(id)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("no job %s", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// writeJSON encodes payload as the response body with the given status code.
+func writeJSON(w http.ResponseWriter, statusCode int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("ERROR: Could not encode JSON response: %v", err)
+	}
+}
+
+// writeJSONError writes a {"error": message} JSON body with the given status code.
+func writeJSONError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]string{"error": message})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	server := &apiServer{apiKey: "s3cr3t"}
+
+	tests := []struct {
+		name      string
+		header    string
+		wantAllow bool
+	}{
+		{"correct key", "s3cr3t", true},
+		{"wrong key", "nope", false},
+		{"missing header", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+			if test.header != "" {
+				request.Header.Set("X-API-Key", test.header)
+			}
+			recorder := httptest.NewRecorder()
+
+			if got := server.requireAPIKey(recorder, request); got != test.wantAllow {
+				t.Errorf("requireAPIKey() = %v, want %v", got, test.wantAllow)
+			}
+		})
+	}
+}
+
+func TestRequireAPIKeyUnconfiguredAllowsEverything(t *testing.T) {
+	server := &apiServer{apiKey: ""}
+	request := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+	recorder := httptest.NewRecorder()
+
+	if !server.requireAPIKey(recorder, request) {
+		t.Error("requireAPIKey() = false with no api-key configured, want true")
+	}
+}
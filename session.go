@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"                // Builds the "too many redirects" error for non-kiatechinfo.com hosts
+	"log"                // Implements a simple logging package
+	"net/http"           // Provides HTTP client and server implementations
+	"net/http/cookiejar" // Automatically stores and replays Set-Cookie headers per host
+	"net/url"            // Resolves techInfoBaseURL to look up its cookies after priming
+	"strings"            // Tests redirect locations/hosts
+	"sync"               // Guards the shared session across concurrent workers
+	"time"               // Tracks how long ago the session was last primed
+)
+
+// --- Session Manager ---
+
+// techInfoSessionTTL bounds how long a primed kiatechinfo.com session is trusted before
+// it's re-primed proactively, even if no request has reported it expired yet.
+const techInfoSessionTTL = 20 * time.Minute
+
+// techInfoBaseURL is the page that triggers kiatechinfo.com's ASP.NET session cookie.
+const techInfoBaseURL = "https://www.kiatechinfo.com/"
+
+// SessionManager owns the single *http.Client (and its cookie jar) shared across
+// kiatechinfo.com and owners.kia.com, replacing the old approach of hand-building a
+// "Name=Value; ..." Cookie header string and passing it through every call. It detects
+// an expired kiatechinfo.com session and transparently re-primes it, so a long-running
+// archival job doesn't die mid-stream when the ASP.NET session rotates.
+type SessionManager struct {
+	client   *http.Client
+	mutex    sync.Mutex
+	primedAt time.Time
+}
+
+// NewSessionManager builds a client with a cookie jar shared across every host it talks
+// to, ready to be primed before the first kiatechinfo.com request.
+func NewSessionManager() (*SessionManager, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionManager{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			Jar:     jar,
+			// Login redirects are how an expired kiatechinfo.com session surfaces; following
+			// them automatically would hide that signal behind a 200 response for the login
+			// page, so stop at the redirect (only for that host) and let
+			// IsExpiredSessionResponse inspect it. owners.kia.com calls share this client
+			// but have no such signal to hide, so they keep the default follow-up-to-10
+			// behavior instead of silently treating a redirect body as the real payload.
+			CheckRedirect: func(request *http.Request, via []*http.Request) error {
+				if strings.Contains(request.URL.Host, "kiatechinfo.com") {
+					return http.ErrUseLastResponse
+				}
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after %d redirects", len(via))
+				}
+				return nil
+			},
+		},
+	}, nil
+}
+
+// EnsureFresh re-primes the kiatechinfo.com session if it has never been primed, or has
+// exceeded its TTL. Safe to call from multiple worker goroutines: the first caller to
+// find it stale re-primes; the rest observe the refreshed session and skip ahead. The
+// priming request itself runs through fetcher, so it's subject to the same rate limit,
+// retry, and stats as every other call.
+func (manager *SessionManager) EnsureFresh(fetcher *Fetcher) error {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	if !manager.primedAt.IsZero() && time.Since(manager.primedAt) <= techInfoSessionTTL {
+		return nil
+	}
+	return manager.primeLocked(fetcher)
+}
+
+// MarkExpired forces the next EnsureFresh call to re-prime, used when a response
+// indicates the session died mid-stream (a login redirect or an unexpectedly empty body).
+func (manager *SessionManager) MarkExpired() {
+	manager.mutex.Lock()
+	manager.primedAt = time.Time{}
+	manager.mutex.Unlock()
+}
+
+// primeLocked performs the priming GET against kiatechinfo.com (through fetcher, so it's
+// rate-limited and retried like every other request) so the jar picks up a fresh
+// ASP.NET session cookie. Callers must hold manager.mutex.
+func (manager *SessionManager) primeLocked(fetcher *Fetcher) error {
+	log.Printf("SESSION: Priming kiatechinfo.com session...")
+
+	_, _, _, err := fetcher.doWithRetry(func() (*http.Request, error) {
+		return http.NewRequest("GET", techInfoBaseURL, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	manager.primedAt = time.Now()
+	baseURL, _ := url.Parse(techInfoBaseURL)
+	log.Printf("SESSION: kiatechinfo.com session primed with %d cookie(s).", len(manager.client.Jar.Cookies(baseURL)))
+	return nil
+}
+
+// IsExpiredSessionResponse reports whether a kiatechinfo.com response looks like the
+// ASP.NET session rotated out from under us: a redirect to a login page, or an
+// unexpectedly empty body.
+func IsExpiredSessionResponse(statusCode int, location string, bodyLength int) bool {
+	if statusCode == http.StatusFound || statusCode == http.StatusMovedPermanently {
+		return strings.Contains(strings.ToLower(location), "login")
+	}
+	return statusCode == http.StatusOK && bodyLength == 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsExpiredSessionResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		location   string
+		bodyLength int
+		want       bool
+	}{
+		{"redirect to login page", http.StatusFound, "https://www.kiatechinfo.com/Login.aspx", 0, true},
+		{"redirect to login page, mixed case", http.StatusMovedPermanently, "https://www.kiatechinfo.com/LOGIN", 0, true},
+		{"redirect elsewhere", http.StatusFound, "https://www.kiatechinfo.com/ext_If/kma_owner_portal/content_pop.aspx", 0, false},
+		{"ok with content", http.StatusOK, "", 1234, false},
+		{"ok with empty body", http.StatusOK, "", 0, true},
+		{"server error", http.StatusInternalServerError, "", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsExpiredSessionResponse(test.statusCode, test.location, test.bodyLength); got != test.want {
+				t.Errorf("IsExpiredSessionResponse(%d, %q, %d) = %v, want %v",
+					test.statusCode, test.location, test.bodyLength, got, test.want)
+			}
+		})
+	}
+}
+
+// TestSessionManagerFollowsNonTechInfoRedirects verifies that the shared client's
+// CheckRedirect only stops at a redirect for kiatechinfo.com; a redirect from any other
+// host (e.g. owners.kia.com) is followed transparently like the http.Client default.
+func TestSessionManagerFollowsNonTechInfoRedirects(t *testing.T) {
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("final response body"))
+	}))
+	defer final.Close()
+
+	redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, final.URL, http.StatusFound)
+	}))
+	defer redirecting.Close()
+
+	manager, err := NewSessionManager()
+	if err != nil {
+		t.Fatalf("NewSessionManager() error: %v", err)
+	}
+
+	response, err := manager.client.Get(redirecting.URL)
+	if err != nil {
+		t.Fatalf("client.Get() error: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("response status = %d, want 200 (the client should have followed the redirect)", response.StatusCode)
+	}
+}
+
+// TestSessionManagerStopsAtTechInfoRedirect verifies that a redirect whose host is
+// kiatechinfo.com is NOT followed, so IsExpiredSessionResponse can inspect it.
+func TestSessionManagerStopsAtTechInfoRedirect(t *testing.T) {
+	manager, err := NewSessionManager()
+	if err != nil {
+		t.Fatalf("NewSessionManager() error: %v", err)
+	}
+
+	request, err := http.NewRequest("GET", "https://www.kiatechinfo.com/ext_If/kma_owner_portal/content_pop.aspx", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error: %v", err)
+	}
+	err = manager.client.CheckRedirect(request, nil)
+	if err != http.ErrUseLastResponse {
+		t.Fatalf("CheckRedirect() for kiatechinfo.com = %v, want http.ErrUseLastResponse", err)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"     // rate.Limiter.Wait requires a context; workers never need to cancel it
+	"errors"      // Lets retry logic test for net.Error via errors.As
+	"fmt"         // Builds the transient-status sentinel error
+	"io"          // Reads response bodies before closing them
+	"log"         // Implements a simple logging package
+	"math/rand"   // Adds jitter to the backoff delay between retries
+	"net"         // Detects transient network errors (timeouts, connection failures)
+	"net/http"    // Provides HTTP client and server implementations
+	"sync"        // Guards shared rate limiter/stats state across worker goroutines
+	"sync/atomic" // Accumulates per-request metrics without a mutex
+	"time"        // Implements the backoff delays and request latency timing
+
+	"golang.org/x/time/rate" // Token-bucket limiter shared by every worker goroutine
+)
+
+// --- Rate Limiting ---
+
+// RateLimiter wraps rate.Limiter, capping outbound requests at a configurable
+// requests-per-second rate shared by every worker goroutine.
+type RateLimiter struct {
+	limiter *rate.Limiter
+}
+
+// NewRateLimiter builds a limiter allowing requestsPerSecond requests per second, with
+// a burst of 1 so requests are paced evenly rather than let through in bursts.
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = 1
+	}
+	return &RateLimiter{limiter: rate.NewLimiter(rate.Limit(requestsPerSecond), 1)}
+}
+
+// Wait blocks until a token is available, throttling the caller to the configured rate.
+func (limiter *RateLimiter) Wait() {
+	// rate.Limiter.Wait only returns an error if the context is cancelled or the burst
+	// is too small to ever admit the request; context.Background() never cancels, and
+	// every caller requests exactly one token against a burst of 1.
+	_ = limiter.limiter.Wait(context.Background())
+}
+
+// Stop is a no-op: rate.Limiter has no background goroutine or ticker to release. Kept
+// so callers (and their defers) don't need to change when the limiter's internals do.
+func (limiter *RateLimiter) Stop() {}
+
+// --- Worker Pool ---
+
+// clampConcurrency rejects a non-positive worker count in favor of 1, so a -concurrency
+// flag of 0 (which would deadlock the first semaphore send) or a negative value (which
+// would panic make(chan struct{}, n)) degrades to sequential processing instead.
+func clampConcurrency(concurrency int) int {
+	if concurrency < 1 {
+		return 1
+	}
+	return concurrency
+}
+
+// --- Retry With Backoff ---
+
+// maxRetryAttempts bounds how many times a single transient failure is retried.
+const maxRetryAttempts = 4
+
+// baseRetryDelay is the starting backoff delay; it doubles on each subsequent attempt.
+const baseRetryDelay = 500 * time.Millisecond
+
+// isTransientStatus reports whether an HTTP status code is worth retrying.
+func isTransientStatus(statusCode int) bool {
+	return statusCode >= 500
+}
+
+// isTransientError reports whether a request-level error (as opposed to a bad status
+// code) looks like a transient timeout or network hiccup worth retrying.
+func isTransientError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// errTransientStatus builds the sentinel error recorded when a response's status code
+// (5xx) is treated as a transient failure worth retrying.
+func errTransientStatus(statusCode int) error {
+	return fmt.Errorf("transient HTTP status %d", statusCode)
+}
+
+// readAndClose drains and closes an HTTP response body, returning whatever bytes were
+// read even if an error occurred partway through.
+func readAndClose(response *http.Response) ([]byte, error) {
+	defer response.Body.Close()
+	return io.ReadAll(response.Body)
+}
+
+// backoffDelay returns the delay before retry attempt number (0-indexed), with up to
+// 50% jitter added so concurrent workers don't all retry in lockstep.
+func backoffDelay(attempt int) time.Duration {
+	backoff := baseRetryDelay * time.Duration(uint(1)<<uint(attempt))
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+	return backoff + jitter
+}
+
+// --- Per-Request Stats ---
+
+// requestStats accumulates attempts, latency, and bytes transferred across every HTTP
+// call made by every worker goroutine, for a summary printed at the end of the run.
+type requestStats struct {
+	attempts     int64
+	retries      int64
+	bytesRead    int64
+	totalLatency int64 // nanoseconds, summed across attempts
+	mutex        sync.Mutex
+}
+
+// recordAttempt registers one HTTP round trip and, if it isn't the first attempt for
+// its logical request, counts it as a retry.
+func (stats *requestStats) recordAttempt(attempt int, latency time.Duration, bytesRead int) {
+	atomic.AddInt64(&stats.attempts, 1)
+	atomic.AddInt64(&stats.bytesRead, int64(bytesRead))
+	atomic.AddInt64(&stats.totalLatency, int64(latency))
+	if attempt > 0 {
+		atomic.AddInt64(&stats.retries, 1)
+	}
+}
+
+// Print logs a one-line summary of everything recorded so far.
+func (stats *requestStats) Print() {
+	attempts := atomic.LoadInt64(&stats.attempts)
+	var averageLatency time.Duration
+	if attempts > 0 {
+		averageLatency = time.Duration(atomic.LoadInt64(&stats.totalLatency) / attempts)
+	}
+	log.Printf("STATS: %d HTTP attempt(s), %d retr(y/ies), %d byte(s) read, %v average latency",
+		attempts, atomic.LoadInt64(&stats.retries), atomic.LoadInt64(&stats.bytesRead), averageLatency)
+}
+
+// --- Fetcher ---
+
+// Fetcher bundles the shared HTTP client (and its session/cookie jar), rate limiter,
+// and stats used by every worker goroutine, so request volume can be capped and
+// sessions reused regardless of concurrency.
+type Fetcher struct {
+	client  *http.Client
+	session *SessionManager
+	limiter *RateLimiter
+	stats   *requestStats
+}
+
+// NewFetcher builds a Fetcher sharing one session (HTTP client + cookie jar) and rate
+// limiter across workers.
+func NewFetcher(requestsPerSecond float64, session *SessionManager) *Fetcher {
+	return &Fetcher{
+		client:  session.client,
+		session: session,
+		limiter: NewRateLimiter(requestsPerSecond),
+		stats:   &requestStats{},
+	}
+}
+
+// doWithRetry runs buildRequest to produce a fresh *http.Request (since a request's
+// body reader can't be replayed) and executes it, retrying transient 5xx/timeout/
+// net.Error failures with exponential backoff and jitter, up to maxRetryAttempts. It
+// also returns the response's Location header (non-empty only for a 3xx response the
+// client didn't follow), so callers can detect a redirect to a login page.
+func (fetcher *Fetcher) doWithRetry(buildRequest func() (*http.Request, error)) ([]byte, int, string, error) {
+	var lastErr error
+	var lastStatus int
+
+	for attempt := 0; attempt < maxRetryAttempts; attempt++ {
+		if attempt > 0 {
+			delay := backoffDelay(attempt - 1)
+			log.Printf("RETRY: Attempt %d/%d after %v backoff (last error: %v)...", attempt+1, maxRetryAttempts, delay, lastErr)
+			time.Sleep(delay)
+		}
+
+		fetcher.limiter.Wait()
+
+		request, err := buildRequest()
+		if err != nil {
+			return nil, 0, "", err
+		}
+
+		started := time.Now()
+		response, err := fetcher.client.Do(request)
+		if err != nil {
+			lastErr = err
+			fetcher.stats.recordAttempt(attempt, time.Since(started), 0)
+			if !isTransientError(err) {
+				return nil, 0, "", err
+			}
+			continue
+		}
+
+		bodyBytes, readErr := readAndClose(response)
+		latency := time.Since(started)
+		fetcher.stats.recordAttempt(attempt, latency, len(bodyBytes))
+		lastStatus = response.StatusCode
+		location := response.Header.Get("Location")
+
+		if readErr != nil {
+			lastErr = readErr
+			continue
+		}
+		if isTransientStatus(response.StatusCode) {
+			lastErr = errTransientStatus(response.StatusCode)
+			continue
+		}
+
+		return bodyBytes, response.StatusCode, location, nil
+	}
+
+	return nil, lastStatus, "", lastErr
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClampConcurrency(t *testing.T) {
+	tests := []struct {
+		concurrency int
+		want        int
+	}{
+		{4, 4},
+		{1, 1},
+		{0, 1},
+		{-1, 1},
+		{-100, 1},
+	}
+
+	for _, test := range tests {
+		if got := clampConcurrency(test.concurrency); got != test.want {
+			t.Errorf("clampConcurrency(%d) = %d, want %d", test.concurrency, got, test.want)
+		}
+	}
+}
+
+func TestIsTransientStatus(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       bool
+	}{
+		{200, false},
+		{404, false},
+		{499, false},
+		{500, true},
+		{503, true},
+		{599, true},
+	}
+
+	for _, test := range tests {
+		if got := isTransientStatus(test.statusCode); got != test.want {
+			t.Errorf("isTransientStatus(%d) = %v, want %v", test.statusCode, got, test.want)
+		}
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	netErr := &net.DNSError{Err: "timeout", IsTimeout: true}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"net.Error", netErr, true},
+		{"wrapped net.Error", fmt.Errorf("request failed: %w", netErr), true},
+		{"plain error", errors.New("something else went wrong"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTransientError(test.err); got != test.want {
+				t.Errorf("isTransientError(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBackoffDelayGrowsAndStaysWithinJitterBound(t *testing.T) {
+	var previousMin time.Duration
+	for attempt := 0; attempt < 4; attempt++ {
+		backoff := baseRetryDelay * time.Duration(uint(1)<<uint(attempt))
+		maxDelay := backoff + backoff/2 // up to 50% jitter
+
+		// Sample a few times since backoffDelay includes randomness.
+		for i := 0; i < 20; i++ {
+			delay := backoffDelay(attempt)
+			if delay < backoff {
+				t.Errorf("backoffDelay(%d) = %v, want at least the base backoff %v", attempt, delay, backoff)
+			}
+			if delay > maxDelay {
+				t.Errorf("backoffDelay(%d) = %v, want at most %v (base + 50%% jitter)", attempt, delay, maxDelay)
+			}
+		}
+
+		if backoff <= previousMin {
+			t.Errorf("attempt %d base backoff %v did not grow from previous attempt", attempt, backoff)
+		}
+		previousMin = backoff
+	}
+}